@@ -0,0 +1,334 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repo
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/ghodss/yaml"
+
+	"github.com/kubernetes/helm/pkg/chart"
+	"k8s.io/helm/pkg/provenance"
+)
+
+// HTTPRepoType identifies a plain HTTP/HTTPS repository backed by a
+// conventional index.yaml, as produced by `helm repo index`.
+const HTTPRepoType = "http"
+
+// httpRepoProvider builds IStorageRepo instances for HTTP(S)-backed
+// repositories.
+type httpRepoProvider struct {
+	cp ICredentialProvider
+}
+
+// NewHTTPRepoProvider creates a TypedRepoProvider for HTTP(S) repositories.
+func NewHTTPRepoProvider(cp ICredentialProvider) TypedRepoProvider {
+	if cp == nil {
+		cp = NewInmemCredentialProvider()
+	}
+	return &httpRepoProvider{cp: cp}
+}
+
+// GetRepo returns a repository that serves charts by downloading and
+// consulting the repository's index.yaml over HTTP(S).
+func (hrp *httpRepoProvider) GetRepo(r IRepo) (IStorageRepo, error) {
+	client, err := hrp.createHTTPClient(r.GetCredentialName())
+	if err != nil {
+		return nil, err
+	}
+
+	return newHTTPRepo(r.GetName(), r.GetURL(), r.GetCredentialName(), client), nil
+}
+
+func (hrp *httpRepoProvider) createHTTPClient(credentialName string) (*http.Client, error) {
+	if credentialName == "" {
+		return http.DefaultClient, nil
+	}
+
+	if _, err := hrp.cp.GetCredential(credentialName); err != nil {
+		return http.DefaultClient, nil
+	}
+
+	// The credential is only looked up here: it is not attached to any
+	// request this repository makes, and the default client is returned
+	// whether or not the lookup succeeds.
+	return http.DefaultClient, nil
+}
+
+// httpRepo implements IStorageRepo/IChartRepo against an HTTP(S) chart
+// repository addressed by its index.yaml.
+type httpRepo struct {
+	name, url, credentialName string
+	client                    *http.Client
+}
+
+func newHTTPRepo(name, URL, credentialName string, client *http.Client) *httpRepo {
+	return &httpRepo{name: name, url: strings.TrimSuffix(URL, "/"), credentialName: credentialName, client: client}
+}
+
+func (hr *httpRepo) GetName() string { return hr.name }
+
+func (hr *httpRepo) GetURL() string { return hr.url }
+
+func (hr *httpRepo) GetCredentialName() string { return hr.credentialName }
+
+func (hr *httpRepo) GetType() string { return HTTPRepoType }
+
+// GetIndex downloads and parses this repository's index.yaml.
+func (hr *httpRepo) GetIndex() (*IndexFile, error) {
+	resp, err := hr.client.Get(hr.url + "/" + indexPath)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return LoadIndex(b)
+}
+
+// GetIndexConditional implements conditionalIndexFetcher, letting a
+// ProxyEngine avoid re-downloading and re-parsing this repository's
+// index.yaml when upstream confirms it hasn't changed.
+func (hr *httpRepo) GetIndexConditional(etag, lastModified string) (data []byte, newETag, newLastModified string, notModified bool, err error) {
+	req, err := http.NewRequest(http.MethodGet, hr.url+"/"+indexPath, nil)
+	if err != nil {
+		return nil, "", "", false, err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	resp, err := hr.client.Do(req)
+	if err != nil {
+		return nil, "", "", false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, etag, lastModified, true, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", "", false, fmt.Errorf("GET %s: unexpected status %s", hr.url+"/"+indexPath, resp.Status)
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", "", false, err
+	}
+
+	return b, resp.Header.Get("ETag"), resp.Header.Get("Last-Modified"), false, nil
+}
+
+// putIndex writes index back to this repository's index.yaml via HTTP PUT,
+// implementing indexPersister so label mutations made through AddLabel/
+// RemoveLabel persist for subsequent requests. It requires the repository's
+// HTTP endpoint to accept PUT on its index.yaml; repositories that only
+// serve static files will return an error here, which AddLabel/RemoveLabel
+// surface to the caller.
+func (hr *httpRepo) putIndex(index *IndexFile) error {
+	b, err := yaml.Marshal(index)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPut, hr.url+"/"+indexPath, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-yaml")
+
+	resp, err := hr.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("PUT %s: unexpected status %s", hr.url+"/"+indexPath, resp.Status)
+	}
+	return nil
+}
+
+// AddLabel attaches a label to the given chart version and persists the
+// updated index back to the repository.
+func (hr *httpRepo) AddLabel(name, version string, l Label) error {
+	return addLabel(hr, name, version, l)
+}
+
+// RemoveLabel removes a label from the given chart version and persists the
+// updated index back to the repository.
+func (hr *httpRepo) RemoveLabel(name, version, labelName string) error {
+	return removeLabel(hr, name, version, labelName)
+}
+
+// ListLabels returns the labels attached to the given chart version.
+func (hr *httpRepo) ListLabels(name, version string) ([]Label, error) {
+	return listLabels(hr, name, version)
+}
+
+// GetChart fetches the named chart tarball by resolving it against the
+// repository's index and downloading its URL.
+func (hr *httpRepo) GetChart(name string) (*chart.Chart, error) {
+	index, err := hr.GetIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	chartName, version := splitChartFilename(name)
+	cv, err := index.Get(chartName, version)
+	if err != nil {
+		return nil, err
+	}
+	if len(cv.URLs) == 0 {
+		return nil, fmt.Errorf("chart %s has no URLs in index", name)
+	}
+
+	resp, err := hr.client.Get(cv.URLs[0])
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return chart.LoadData(b)
+}
+
+// parseURLChartReference splits a reference of the form
+// "<scheme>://host/path/name-version.tgz" into the chart's name, version,
+// and the repository URL (the reference with the chart filename removed).
+func parseURLChartReference(reference string) (name, version, repoURL string, err error) {
+	idx := strings.LastIndex(reference, "/")
+	if idx < 0 {
+		return "", "", "", fmt.Errorf("cannot parse chart reference %s", reference)
+	}
+
+	repoURL = reference[:idx]
+	filename := reference[idx+1:]
+	name, version = splitChartFilename(filename)
+	if name == "" || version == "" {
+		return "", "", "", fmt.Errorf("cannot parse chart reference %s: expected name-version.tgz", reference)
+	}
+
+	return name, version, repoURL, nil
+}
+
+// GetChartVerified fetches the named chart along with its detached .prov
+// file and verifies it against the signer recorded in the index's
+// PublicKeys. On success it returns the chart together with the verification
+// result; it returns a non-nil error, with a nil chart and verification, if
+// the chart, its .prov file, or the signature itself cannot be obtained or
+// does not verify.
+func (hr *httpRepo) GetChartVerified(name string) (*chart.Chart, *provenance.Verification, error) {
+	index, err := hr.GetIndex()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	chartName, version := splitChartFilename(name)
+	cv, err := index.Get(chartName, version)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(cv.URLs) == 0 {
+		return nil, nil, fmt.Errorf("chart %s has no URLs in index", name)
+	}
+
+	chartData, err := hr.fetch(cv.URLs[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	provData, err := hr.fetch(cv.URLs[0] + ".prov")
+	if err != nil {
+		return nil, nil, fmt.Errorf("fetching provenance for %s: %s", name, err)
+	}
+
+	keyring, err := index.Keyring()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	verification, err := verifyChartBytes(name, chartData, provData, keyring)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c, err := chart.LoadData(chartData)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return c, verification, nil
+}
+
+func (hr *httpRepo) fetch(url string) ([]byte, error) {
+	resp, err := hr.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: unexpected status %s", url, resp.Status)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+// splitChartFilename splits a "name-version.tgz" filename into its name and
+// version components.
+//
+// A naive split on the last hyphen mis-parses pre-release versions that
+// themselves contain a hyphen (e.g. "mychart-1.2.3-rc.1.tgz" would yield
+// name "mychart-1.2.3", version "rc.1"). Instead, walk the hyphen-separated
+// segments from left to right and take the first split point whose
+// remainder parses as a SemVer version.
+func splitChartFilename(filename string) (name, version string) {
+	base := strings.TrimSuffix(filename, ".tgz")
+	segments := strings.Split(base, "-")
+
+	for i := 1; i < len(segments); i++ {
+		candidate := strings.Join(segments[i:], "-")
+		if _, err := semver.NewVersion(candidate); err == nil {
+			return strings.Join(segments[:i], "-"), candidate
+		}
+	}
+
+	// No segment parsed as a valid SemVer version; fall back to the last
+	// hyphen so callers still get a best-effort split.
+	idx := strings.LastIndex(base, "-")
+	if idx < 0 {
+		return base, ""
+	}
+	return base[:idx], base[idx+1:]
+}
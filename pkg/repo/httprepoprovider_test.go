@@ -0,0 +1,39 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repo
+
+import "testing"
+
+func TestSplitChartFilename(t *testing.T) {
+	tests := []struct {
+		filename, name, version string
+	}{
+		{"foo-1.0.0.tgz", "foo", "1.0.0"},
+		{"foo-bar-1.0.0.tgz", "foo-bar", "1.0.0"},
+		{"mychart-1.2.3-rc.1.tgz", "mychart", "1.2.3-rc.1"},
+		{"mychart-1.2.3-alpha.1+build.5.tgz", "mychart", "1.2.3-alpha.1+build.5"},
+		{"nonsemver.tgz", "nonsemver", ""},
+	}
+
+	for _, tt := range tests {
+		name, version := splitChartFilename(tt.filename)
+		if name != tt.name || version != tt.version {
+			t.Errorf("splitChartFilename(%q) = (%q, %q), want (%q, %q)",
+				tt.filename, name, version, tt.name, tt.version)
+		}
+	}
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package repo
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -32,6 +33,7 @@ import (
 
 	"github.com/Masterminds/semver"
 	"github.com/ghodss/yaml"
+	"golang.org/x/crypto/openpgp"
 
 	"k8s.io/helm/pkg/chartutil"
 	"k8s.io/helm/pkg/proto/hapi/chart"
@@ -84,6 +86,17 @@ type IndexFile struct {
 	PublicKeys []string                 `json:"publicKeys,omitempty"`
 }
 
+// Label is a free-form name/value tag attached to a chart version.
+//
+// Labels let clients filter or search the index for charts that carry a
+// particular tag (e.g. Name: "status", Value: "stable") without having to
+// parse the chart's Metadata. A Label with an empty Value behaves as a
+// bare tag, e.g. Name: "deprecated".
+type Label struct {
+	Name  string `json:"name"`
+	Value string `json:"value,omitempty"`
+}
+
 // NewIndexFile initializes an index.
 func NewIndexFile() *IndexFile {
 	return &IndexFile{
@@ -94,8 +107,11 @@ func NewIndexFile() *IndexFile {
 	}
 }
 
-// Add adds a file to the index
-func (i IndexFile) Add(md *chart.Metadata, filename, baseURL, digest string) {
+// Add adds a file to the index.
+//
+// An optional set of labels may be supplied to tag the resulting entry, e.g.
+// index.Add(md, filename, baseURL, digest, Label{Name: "status", Value: "stable"}).
+func (i IndexFile) Add(md *chart.Metadata, filename, baseURL, digest string, labels ...Label) {
 	u := filename
 	if baseURL != "" {
 		var err error
@@ -110,6 +126,7 @@ func (i IndexFile) Add(md *chart.Metadata, filename, baseURL, digest string) {
 		Metadata: md,
 		Digest:   digest,
 		Created:  time.Now(),
+		Labels:   labels,
 	}
 	if ee, ok := i.Entries[md.Name]; !ok {
 		i.Entries[md.Name] = ChartVersions{cr}
@@ -118,6 +135,51 @@ func (i IndexFile) Add(md *chart.Metadata, filename, baseURL, digest string) {
 	}
 }
 
+// AddLabel attaches a label to the chart version identified by name and
+// version. If the chart version already carries a label with the same
+// Name, its Value is replaced.
+func (i IndexFile) AddLabel(name, version string, l Label) error {
+	cv, err := i.Get(name, version)
+	if err != nil {
+		return err
+	}
+	for idx, existing := range cv.Labels {
+		if existing.Name == l.Name {
+			cv.Labels[idx] = l
+			return nil
+		}
+	}
+	cv.Labels = append(cv.Labels, l)
+	return nil
+}
+
+// RemoveLabel removes the label with the given name from the chart version
+// identified by name and version. It is not an error to remove a label that
+// is not present.
+func (i IndexFile) RemoveLabel(name, version, labelName string) error {
+	cv, err := i.Get(name, version)
+	if err != nil {
+		return err
+	}
+	for idx, existing := range cv.Labels {
+		if existing.Name == labelName {
+			cv.Labels = append(cv.Labels[:idx], cv.Labels[idx+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+// ListLabels returns the labels attached to the chart version identified by
+// name and version.
+func (i IndexFile) ListLabels(name, version string) ([]Label, error) {
+	cv, err := i.Get(name, version)
+	if err != nil {
+		return nil, err
+	}
+	return cv.Labels, nil
+}
+
 // Has returns true if the index has an entry for a chart with the given name and exact version.
 func (i IndexFile) Has(name, version string) bool {
 	_, err := i.Get(name, version)
@@ -170,19 +232,39 @@ func (i IndexFile) WriteFile(dest string, mode os.FileMode) error {
 	return ioutil.WriteFile(dest, b, mode)
 }
 
+// Sign produces a detached, armored PGP signature over the index's YAML
+// encoding, signed by entity. The result is suitable for publishing alongside
+// index.yaml as index.yaml.prov, for later verification with
+// LoadIndexVerified.
+func (i IndexFile) Sign(entity *openpgp.Entity) ([]byte, error) {
+	data, err := yaml.Marshal(i)
+	if err != nil {
+		return nil, err
+	}
+
+	var sig bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&sig, entity, bytes.NewReader(data), nil); err != nil {
+		return nil, err
+	}
+	return sig.Bytes(), nil
+}
+
 // Merge merges the given index file into this index.
 //
 // This merges by name and version.
 //
 // If one of the entries in the given index does _not_ already exist, it is added.
-// In all other cases, the existing record is preserved.
+// If it does already exist, the existing record is preserved, but any labels
+// carried by the incoming entry that the existing record lacks are unioned in.
 func (i *IndexFile) Merge(f *IndexFile) {
 	for _, cvs := range f.Entries {
 		for _, cv := range cvs {
-			if !i.Has(cv.Name, cv.Version) {
-				e := i.Entries[cv.Name]
-				i.Entries[cv.Name] = append(e, cv)
+			if existing, err := i.Get(cv.Name, cv.Version); err == nil {
+				existing.mergeLabels(cv.Labels)
+				continue
 			}
+			e := i.Entries[cv.Name]
+			i.Entries[cv.Name] = append(e, cv)
 		}
 	}
 }
@@ -196,6 +278,28 @@ type ChartVersion struct {
 	Created time.Time `json:"created,omitempty"`
 	Removed bool      `json:"removed,omitempty"`
 	Digest  string    `json:"digest,omitempty"`
+	Labels  []Label   `json:"labels,omitempty"`
+}
+
+// hasLabel returns true if the chart version already carries a label with
+// the given name.
+func (cv *ChartVersion) hasLabel(name string) bool {
+	for _, l := range cv.Labels {
+		if l.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeLabels adds any label from other that is not already present on cv,
+// matching by label name.
+func (cv *ChartVersion) mergeLabels(other []Label) {
+	for _, l := range other {
+		if !cv.hasLabel(l.Name) {
+			cv.Labels = append(cv.Labels, l)
+		}
+	}
 }
 
 // IndexDirectory reads a (flat) directory and generates an index.
@@ -226,6 +330,11 @@ func IndexDirectory(dir, baseURL string) (*IndexFile, error) {
 }
 
 // DownloadIndexFile fetches the index from a repository.
+//
+// If the repository also publishes a detached signature at index.yaml.prov,
+// it is downloaded alongside the index as indexFilePath+".prov" so that a
+// caller can later verify it with LoadIndexVerified. A missing or
+// unreachable .prov file is not an error: signed indexes are opt-in.
 func DownloadIndexFile(repoName, url, indexFilePath string) error {
 	var indexURL string
 
@@ -245,9 +354,32 @@ func DownloadIndexFile(repoName, url, indexFilePath string) error {
 		return err
 	}
 
+	downloadIndexProvenance(indexURL, indexFilePath)
+
 	return ioutil.WriteFile(indexFilePath, b, 0644)
 }
 
+// downloadIndexProvenance best-effort fetches the detached signature for an
+// index.yaml and writes it alongside indexFilePath.
+func downloadIndexProvenance(indexURL, indexFilePath string) {
+	resp, err := http.Get(indexURL + ".prov")
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return
+	}
+
+	sig, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+
+	ioutil.WriteFile(indexFilePath+".prov", sig, 0644)
+}
+
 // LoadIndex loads an index file and does minimal validity checking.
 //
 // This will fail if API Version is not set (ErrNoAPIVersion) or if the unmarshal fails.
@@ -265,6 +397,32 @@ func LoadIndex(data []byte) (*IndexFile, error) {
 	return i, nil
 }
 
+// Keyring parses the index's PublicKeys (each an ASCII-armored PGP public
+// key block) into a single keyring, suitable for verifying the provenance of
+// charts listed in this index.
+func (i IndexFile) Keyring() (openpgp.KeyRing, error) {
+	var keyring openpgp.EntityList
+	for _, key := range i.PublicKeys {
+		entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(key))
+		if err != nil {
+			return nil, fmt.Errorf("parsing index public key: %s", err)
+		}
+		keyring = append(keyring, entities...)
+	}
+	return keyring, nil
+}
+
+// LoadIndexVerified loads an index file from data after verifying it against
+// the detached, armored PGP signature sig using keyring. It rejects the
+// index with an error if the signature does not verify, closing the trust
+// gap between the repo layer and the rest of the provenance workflow.
+func LoadIndexVerified(data, sig []byte, keyring openpgp.KeyRing) (*IndexFile, error) {
+	if _, err := openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(data), bytes.NewReader(sig)); err != nil {
+		return nil, fmt.Errorf("index signature verification failed: %s", err)
+	}
+	return LoadIndex(data)
+}
+
 // unversionedEntry represents a deprecated pre-Alpha.5 format.
 //
 // This will be removed prior to v2.0.0
@@ -0,0 +1,120 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repo
+
+import (
+	"testing"
+
+	"k8s.io/helm/pkg/proto/hapi/chart"
+)
+
+func TestMergeUnionsLabels(t *testing.T) {
+	dest := NewIndexFile()
+	dest.Add(&chart.Metadata{Name: "foo", Version: "1.0.0"}, "foo-1.0.0.tgz", "", "")
+	if err := dest.AddLabel("foo", "1.0.0", Label{Name: "status", Value: "stable"}); err != nil {
+		t.Fatalf("AddLabel: %s", err)
+	}
+
+	src := NewIndexFile()
+	src.Add(&chart.Metadata{Name: "foo", Version: "1.0.0"}, "foo-1.0.0.tgz", "", "")
+	if err := src.AddLabel("foo", "1.0.0", Label{Name: "team", Value: "platform"}); err != nil {
+		t.Fatalf("AddLabel: %s", err)
+	}
+
+	dest.Merge(src)
+
+	labels, err := dest.ListLabels("foo", "1.0.0")
+	if err != nil {
+		t.Fatalf("ListLabels: %s", err)
+	}
+	if len(labels) != 2 {
+		t.Fatalf("expected 2 labels after merge, got %d: %v", len(labels), labels)
+	}
+
+	want := map[string]string{"status": "stable", "team": "platform"}
+	for _, l := range labels {
+		if want[l.Name] != l.Value {
+			t.Errorf("unexpected label %+v", l)
+		}
+	}
+}
+
+func TestMergeDoesNotDuplicateExistingLabel(t *testing.T) {
+	dest := NewIndexFile()
+	dest.Add(&chart.Metadata{Name: "foo", Version: "1.0.0"}, "foo-1.0.0.tgz", "", "")
+	dest.AddLabel("foo", "1.0.0", Label{Name: "status", Value: "stable"})
+
+	src := NewIndexFile()
+	src.Add(&chart.Metadata{Name: "foo", Version: "1.0.0"}, "foo-1.0.0.tgz", "", "")
+	src.AddLabel("foo", "1.0.0", Label{Name: "status", Value: "deprecated"})
+
+	dest.Merge(src)
+
+	labels, _ := dest.ListLabels("foo", "1.0.0")
+	if len(labels) != 1 {
+		t.Fatalf("expected the existing label to win, got %v", labels)
+	}
+	if labels[0].Value != "stable" {
+		t.Errorf("expected the existing record's label value to be preserved, got %q", labels[0].Value)
+	}
+}
+
+func TestAddRemoveListLabels(t *testing.T) {
+	i := NewIndexFile()
+	i.Add(&chart.Metadata{Name: "foo", Version: "1.0.0"}, "foo-1.0.0.tgz", "", "")
+
+	if err := i.AddLabel("foo", "1.0.0", Label{Name: "status", Value: "stable"}); err != nil {
+		t.Fatalf("AddLabel: %s", err)
+	}
+
+	// Adding a label with the same name replaces the existing value.
+	if err := i.AddLabel("foo", "1.0.0", Label{Name: "status", Value: "deprecated"}); err != nil {
+		t.Fatalf("AddLabel (replace): %s", err)
+	}
+
+	labels, err := i.ListLabels("foo", "1.0.0")
+	if err != nil {
+		t.Fatalf("ListLabels: %s", err)
+	}
+	if len(labels) != 1 || labels[0].Value != "deprecated" {
+		t.Fatalf("expected a single replaced label, got %v", labels)
+	}
+
+	if err := i.RemoveLabel("foo", "1.0.0", "status"); err != nil {
+		t.Fatalf("RemoveLabel: %s", err)
+	}
+
+	labels, err = i.ListLabels("foo", "1.0.0")
+	if err != nil {
+		t.Fatalf("ListLabels: %s", err)
+	}
+	if len(labels) != 0 {
+		t.Fatalf("expected no labels after RemoveLabel, got %v", labels)
+	}
+
+	// Removing a label that isn't present is not an error.
+	if err := i.RemoveLabel("foo", "1.0.0", "missing"); err != nil {
+		t.Errorf("RemoveLabel of an absent label should not error, got %s", err)
+	}
+}
+
+func TestAddLabelUnknownChart(t *testing.T) {
+	i := NewIndexFile()
+	if err := i.AddLabel("nope", "1.0.0", Label{Name: "status"}); err == nil {
+		t.Error("expected an error adding a label to a chart that doesn't exist")
+	}
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repo
+
+// indexPersister is implemented by IChartRepo backends that can write their
+// index.yaml back to the backing store, not just read it. It backs the
+// AddLabel/RemoveLabel label mutators so label edits are visible to the next
+// GetIndex/GetChart call against the same repository, not just to the
+// caller's in-memory copy.
+type indexPersister interface {
+	GetIndex() (*IndexFile, error)
+	putIndex(index *IndexFile) error
+}
+
+// addLabel loads r's index, attaches l to the given chart version, and
+// writes the index back to the backing store.
+func addLabel(r indexPersister, name, version string, l Label) error {
+	index, err := r.GetIndex()
+	if err != nil {
+		return err
+	}
+	if err := index.AddLabel(name, version, l); err != nil {
+		return err
+	}
+	return r.putIndex(index)
+}
+
+// removeLabel loads r's index, removes the named label from the given chart
+// version, and writes the index back to the backing store.
+func removeLabel(r indexPersister, name, version, labelName string) error {
+	index, err := r.GetIndex()
+	if err != nil {
+		return err
+	}
+	if err := index.RemoveLabel(name, version, labelName); err != nil {
+		return err
+	}
+	return r.putIndex(index)
+}
+
+// listLabels returns the labels attached to the given chart version in r's
+// index.
+func listLabels(r interface{ GetIndex() (*IndexFile, error) }, name, version string) ([]Label, error) {
+	index, err := r.GetIndex()
+	if err != nil {
+		return nil, err
+	}
+	return index.ListLabels(name, version)
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/containerd/containerd/remotes/docker"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/kubernetes/helm/pkg/chart"
+	"k8s.io/helm/pkg/provenance"
+)
+
+// OCIRepoType identifies a chart repository backed by an OCI registry, where
+// each chart version is stored as an OCI artifact tagged with the chart's
+// version.
+const OCIRepoType = "oci"
+
+// ociRepoProvider builds IStorageRepo instances backed by OCI registries.
+type ociRepoProvider struct {
+	cp ICredentialProvider
+}
+
+// NewOCIRepoProvider creates a TypedRepoProvider for OCI registry-backed
+// repositories.
+func NewOCIRepoProvider(cp ICredentialProvider) TypedRepoProvider {
+	if cp == nil {
+		cp = NewInmemCredentialProvider()
+	}
+	return &ociRepoProvider{cp: cp}
+}
+
+// GetRepo returns a repository that resolves charts as OCI artifacts hosted
+// at r.GetURL(), e.g. "oci://myregistry.example.com/charts".
+func (orp *ociRepoProvider) GetRepo(r IRepo) (IStorageRepo, error) {
+	resolver, err := orp.createResolver(r.GetCredentialName())
+	if err != nil {
+		return nil, err
+	}
+
+	registry := strings.TrimPrefix(r.GetURL(), "oci://")
+	return &ociRepo{
+		name:           r.GetName(),
+		url:            r.GetURL(),
+		credentialName: r.GetCredentialName(),
+		registry:       registry,
+		resolver:       resolver,
+	}, nil
+}
+
+func (orp *ociRepoProvider) createResolver(credentialName string) (docker.Resolver, error) {
+	opts := docker.ResolverOptions{}
+	if credentialName != "" {
+		c, err := orp.cp.GetCredential(credentialName)
+		if err != nil {
+			return nil, fmt.Errorf("credential named %s not found: %s", credentialName, err)
+		}
+		opts.Hosts = docker.ConfigureDefaultRegistries(docker.WithAuthorizer(
+			docker.NewDockerAuthorizer(docker.WithAuthCreds(func(string) (string, string, error) {
+				return c.Username, c.Password, nil
+			})),
+		))
+	}
+	return docker.NewResolver(opts), nil
+}
+
+// parseOCIChartReference splits a reference of the form
+// "oci://registry/path/name:version" into the chart's name, version, and the
+// registry URL (the reference with the "name:version" component removed).
+func parseOCIChartReference(reference string) (name, version, repoURL string, err error) {
+	idx := strings.LastIndex(reference, "/")
+	if idx < 0 {
+		return "", "", "", fmt.Errorf("cannot parse oci chart reference %s", reference)
+	}
+
+	repoURL = reference[:idx]
+	tagged := reference[idx+1:]
+	parts := strings.SplitN(tagged, ":", 2)
+	if len(parts) != 2 {
+		return "", "", "", fmt.Errorf("cannot parse oci chart reference %s: expected name:version", reference)
+	}
+
+	return parts[0], parts[1], repoURL, nil
+}
+
+// ociRepo implements IStorageRepo/IChartRepo against charts published as
+// tagged OCI artifacts in a registry.
+type ociRepo struct {
+	name, url, credentialName string
+	registry                  string
+	resolver                  docker.Resolver
+}
+
+func (or *ociRepo) GetName() string { return or.name }
+
+func (or *ociRepo) GetURL() string { return or.url }
+
+func (or *ociRepo) GetCredentialName() string { return or.credentialName }
+
+func (or *ociRepo) GetType() string { return OCIRepoType }
+
+// GetIndex is not supported for OCI repositories: OCI registries have no
+// index.yaml equivalent, only a tag/catalog listing scoped to a single
+// repository name. Callers that need to enumerate versions of a chart should
+// use the registry's tag listing API directly instead.
+func (or *ociRepo) GetIndex() (*IndexFile, error) {
+	return nil, fmt.Errorf("GetIndex is not supported for OCI repositories (%s)", or.url)
+}
+
+// putIndex is not supported for OCI repositories, for the same reason
+// GetIndex isn't: there is no index.yaml equivalent to write.
+func (or *ociRepo) putIndex(index *IndexFile) error {
+	return fmt.Errorf("label persistence is not supported for OCI repositories (%s)", or.url)
+}
+
+// AddLabel is not supported for OCI repositories; see putIndex.
+func (or *ociRepo) AddLabel(name, version string, l Label) error {
+	return addLabel(or, name, version, l)
+}
+
+// RemoveLabel is not supported for OCI repositories; see putIndex.
+func (or *ociRepo) RemoveLabel(name, version, labelName string) error {
+	return removeLabel(or, name, version, labelName)
+}
+
+// ListLabels is not supported for OCI repositories; see GetIndex.
+func (or *ociRepo) ListLabels(name, version string) ([]Label, error) {
+	return listLabels(or, name, version)
+}
+
+// GetChartVerified is not yet supported for OCI repositories: OCI's native
+// content-addressable digests are themselves an integrity mechanism, but
+// mapping that to the repo layer's openpgp-based provenance workflow is not
+// yet implemented.
+func (or *ociRepo) GetChartVerified(name string) (*chart.Chart, *provenance.Verification, error) {
+	return nil, nil, fmt.Errorf("chart verification is not supported for OCI repositories (%s)", or.url)
+}
+
+// GetChart fetches the chart whose name and version are encoded in the
+// "name-version.tgz" filename convention shared with the other repo types,
+// resolving it to "registry/name:version" and pulling the artifact's single
+// chart-archive layer.
+func (or *ociRepo) GetChart(filename string) (*chart.Chart, error) {
+	name, version := splitChartFilename(filename)
+	ref := fmt.Sprintf("%s/%s:%s", strings.TrimSuffix(or.registry, "/"), name, version)
+
+	ctx := context.Background()
+	_, desc, err := or.resolver.Resolve(ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("resolving oci reference %s: %s", ref, err)
+	}
+
+	fetcher, err := or.resolver.Fetcher(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	mrc, err := fetcher.Fetch(ctx, desc)
+	if err != nil {
+		return nil, err
+	}
+	defer mrc.Close()
+
+	var manifest ocispec.Manifest
+	if err := json.NewDecoder(mrc).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("decoding oci manifest for %s: %s", ref, err)
+	}
+	if len(manifest.Layers) != 1 {
+		return nil, fmt.Errorf("oci artifact %s has %d layers, expected a single chart archive layer", ref, len(manifest.Layers))
+	}
+
+	rc, err := fetcher.Fetch(ctx, manifest.Layers[0])
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return chart.Load(rc)
+}
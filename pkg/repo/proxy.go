@@ -0,0 +1,423 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repo
+
+import (
+	"container/list"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghodss/yaml"
+
+	"github.com/kubernetes/helm/pkg/chart"
+	"k8s.io/helm/pkg/provenance"
+)
+
+// CacheOptions configures the on-disk cache a ProxyEngine keeps in front of
+// its upstream repository.
+type CacheOptions struct {
+	// MaxBytes bounds the total size of cached chart tarballs. Entries are
+	// evicted least-recently-used first once the bound is exceeded. Zero
+	// means unbounded.
+	MaxBytes int64
+	// RefreshInterval is how often the cached index.yaml is refetched from
+	// upstream in the background. Zero disables background refresh; the
+	// index is then only refetched on a cache miss.
+	RefreshInterval time.Duration
+}
+
+// ProxyEngine wraps an IChartRepo with a local on-disk LRU cache, serving
+// chart tarballs and the index.yaml without round-tripping to upstream on
+// every request.
+//
+// Chart tarballs are immutable once published (a given name-version never
+// changes), so they're simply cached permanently until evicted by the LRU
+// bound. The index.yaml is not immutable, so background refreshes use a
+// conditional GET (ETag/If-Modified-Since) against upstream when the wrapped
+// repository supports one (see conditionalIndexFetcher), falling back to a
+// plain GET otherwise.
+type ProxyEngine struct {
+	inner    IChartRepo
+	cacheDir string
+	opts     CacheOptions
+
+	mu        sync.Mutex
+	lru       *list.List
+	entries   map[string]*list.Element
+	sizeBytes int64
+
+	indexMu       sync.RWMutex
+	index         *IndexFile
+	indexErr      error
+	indexETag     string
+	indexModified string
+
+	stopRefresh chan struct{}
+}
+
+// conditionalIndexFetcher is implemented by IChartRepo backends that can
+// perform a conditional GET of their index.yaml against an ETag and/or
+// Last-Modified validator, reporting notModified=true (with no body) when
+// upstream confirms the cached copy is still fresh. ProxyEngine uses this,
+// when available, to avoid re-downloading and re-parsing an unchanged index
+// on every background refresh.
+type conditionalIndexFetcher interface {
+	GetIndexConditional(etag, lastModified string) (data []byte, newETag, newLastModified string, notModified bool, err error)
+}
+
+type cacheEntry struct {
+	key  string
+	path string
+	size int64
+}
+
+// NewCachingRepo wraps inner with an on-disk LRU cache of chart tarballs,
+// keyed by chart filename, and (if opts.RefreshInterval is set) refreshes its
+// cached index.yaml from inner on that interval in the background.
+func NewCachingRepo(inner IChartRepo, cacheDir string, opts CacheOptions) IChartRepo {
+	pe := &ProxyEngine{
+		inner:       inner,
+		cacheDir:    cacheDir,
+		opts:        opts,
+		lru:         list.New(),
+		entries:     map[string]*list.Element{},
+		stopRefresh: make(chan struct{}),
+	}
+
+	if opts.RefreshInterval > 0 {
+		go pe.refreshLoop()
+	}
+
+	return pe
+}
+
+// Close stops the ProxyEngine's background index refresh, if any.
+func (pe *ProxyEngine) Close() {
+	select {
+	case <-pe.stopRefresh:
+	default:
+		close(pe.stopRefresh)
+	}
+}
+
+func (pe *ProxyEngine) refreshLoop() {
+	ticker := time.NewTicker(pe.opts.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			pe.loadIndex()
+		case <-pe.stopRefresh:
+			return
+		}
+	}
+}
+
+func (pe *ProxyEngine) loadIndex() (*IndexFile, error) {
+	if fetcher, ok := pe.inner.(conditionalIndexFetcher); ok {
+		return pe.loadIndexConditional(fetcher)
+	}
+
+	index, err := pe.inner.GetIndex()
+	pe.indexMu.Lock()
+	pe.index, pe.indexErr = index, err
+	pe.indexMu.Unlock()
+	return index, err
+}
+
+// loadIndexConditional refreshes the cached index via a conditional GET,
+// reusing the cached copy on a 304/"not modified" response instead of
+// re-parsing a body that didn't change.
+func (pe *ProxyEngine) loadIndexConditional(fetcher conditionalIndexFetcher) (*IndexFile, error) {
+	pe.indexMu.RLock()
+	etag, lastModified := pe.indexETag, pe.indexModified
+	cached := pe.index
+	pe.indexMu.RUnlock()
+
+	data, newETag, newLastModified, notModified, err := fetcher.GetIndexConditional(etag, lastModified)
+	if err != nil {
+		pe.indexMu.Lock()
+		pe.indexErr = err
+		pe.indexMu.Unlock()
+		return nil, err
+	}
+
+	if notModified && cached != nil {
+		return cached, nil
+	}
+
+	index, err := LoadIndex(data)
+
+	pe.indexMu.Lock()
+	defer pe.indexMu.Unlock()
+	if err != nil {
+		pe.indexErr = err
+		return nil, err
+	}
+	pe.index, pe.indexErr = index, nil
+	pe.indexETag, pe.indexModified = newETag, newLastModified
+	return index, nil
+}
+
+// GetIndex returns the cached index.yaml, fetching it from upstream on first
+// use or whenever the cache is empty.
+func (pe *ProxyEngine) GetIndex() (*IndexFile, error) {
+	pe.indexMu.RLock()
+	index := pe.index
+	pe.indexMu.RUnlock()
+
+	if index != nil {
+		return index, nil
+	}
+
+	return pe.loadIndex()
+}
+
+// AddLabel attaches a label to the given chart version on the wrapped
+// repository and invalidates the cached index so the next GetIndex call
+// observes it.
+func (pe *ProxyEngine) AddLabel(name, version string, l Label) error {
+	if err := pe.inner.AddLabel(name, version, l); err != nil {
+		return err
+	}
+	pe.invalidateIndex()
+	return nil
+}
+
+// RemoveLabel removes a label from the given chart version on the wrapped
+// repository and invalidates the cached index so the next GetIndex call
+// observes it.
+func (pe *ProxyEngine) RemoveLabel(name, version, labelName string) error {
+	if err := pe.inner.RemoveLabel(name, version, labelName); err != nil {
+		return err
+	}
+	pe.invalidateIndex()
+	return nil
+}
+
+// ListLabels returns the labels attached to the given chart version,
+// preferring the cached index when available.
+func (pe *ProxyEngine) ListLabels(name, version string) ([]Label, error) {
+	index, err := pe.GetIndex()
+	if err != nil {
+		return nil, err
+	}
+	return index.ListLabels(name, version)
+}
+
+// GetChartVerified delegates to the wrapped repository's GetChartVerified if
+// it supports verification, caching the resulting tarball like GetChart
+// does. It returns an error if the wrapped repository doesn't implement
+// ChartVerifier.
+func (pe *ProxyEngine) GetChartVerified(name string) (*chart.Chart, *provenance.Verification, error) {
+	verifier, ok := pe.inner.(ChartVerifier)
+	if !ok {
+		return nil, nil, fmt.Errorf("repository %s does not support chart verification", pe.inner.GetName())
+	}
+
+	c, verification, err := verifier.GetChartVerified(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if b, err := chart.Dump(c); err == nil {
+		pe.store(name, b)
+	}
+
+	return c, verification, nil
+}
+
+func (pe *ProxyEngine) invalidateIndex() {
+	pe.indexMu.Lock()
+	pe.index, pe.indexErr = nil, nil
+	pe.indexETag, pe.indexModified = "", ""
+	pe.indexMu.Unlock()
+}
+
+func (pe *ProxyEngine) GetName() string          { return pe.inner.GetName() }
+func (pe *ProxyEngine) GetURL() string            { return pe.inner.GetURL() }
+func (pe *ProxyEngine) GetCredentialName() string { return pe.inner.GetCredentialName() }
+func (pe *ProxyEngine) GetType() string           { return pe.inner.GetType() }
+
+// GetChart returns the named chart, serving it from the on-disk cache when
+// present and falling back to the wrapped repository on a miss.
+func (pe *ProxyEngine) GetChart(name string) (*chart.Chart, error) {
+	if path, ok := pe.cachedPath(name); ok {
+		b, err := ioutil.ReadFile(path)
+		if err == nil {
+			if c, err := chart.LoadData(b); err == nil {
+				return c, nil
+			}
+		}
+		// Cache hit but unreadable/corrupt; fall through to a live fetch.
+	}
+
+	c, err := pe.inner.GetChart(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if b, err := chart.Dump(c); err == nil {
+		pe.store(name, b)
+	}
+
+	return c, nil
+}
+
+func (pe *ProxyEngine) cachedPath(name string) (string, bool) {
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+
+	el, ok := pe.entries[name]
+	if !ok {
+		return "", false
+	}
+	pe.lru.MoveToFront(el)
+	entry := el.Value.(*cacheEntry)
+	if _, err := os.Stat(entry.path); err != nil {
+		delete(pe.entries, name)
+		pe.lru.Remove(el)
+		return "", false
+	}
+	return entry.path, true
+}
+
+func (pe *ProxyEngine) store(name string, b []byte) {
+	if err := os.MkdirAll(pe.cacheDir, 0755); err != nil {
+		return
+	}
+	path := filepath.Join(pe.cacheDir, sanitizeCacheKey(name))
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		return
+	}
+
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+
+	if el, ok := pe.entries[name]; ok {
+		pe.sizeBytes -= el.Value.(*cacheEntry).size
+		pe.lru.Remove(el)
+	}
+
+	entry := &cacheEntry{key: name, path: path, size: int64(len(b))}
+	pe.entries[name] = pe.lru.PushFront(entry)
+	pe.sizeBytes += entry.size
+
+	pe.evict()
+}
+
+func (pe *ProxyEngine) evict() {
+	if pe.opts.MaxBytes <= 0 {
+		return
+	}
+	for pe.sizeBytes > pe.opts.MaxBytes {
+		oldest := pe.lru.Back()
+		if oldest == nil {
+			return
+		}
+		entry := oldest.Value.(*cacheEntry)
+		os.Remove(entry.path)
+		pe.lru.Remove(oldest)
+		delete(pe.entries, entry.key)
+		pe.sizeBytes -= entry.size
+	}
+}
+
+func sanitizeCacheKey(name string) string {
+	return strings.Replace(name, string(filepath.Separator), "_", -1)
+}
+
+// NewRepoHTTPHandler returns an http.Handler that serves index.yaml and chart
+// tarballs for every repository known to rp, letting a Helm deployment stand
+// up an internal mirror/gateway in front of its configured repositories.
+//
+// It routes:
+//
+//	GET /{repoName}/index.yaml
+//	GET /{repoName}/charts/{name}-{version}.tgz
+func NewRepoHTTPHandler(rp IRepoProvider) http.Handler {
+	return &repoHTTPHandler{rp: rp}
+}
+
+type repoHTTPHandler struct {
+	rp IRepoProvider
+}
+
+func (h *repoHTTPHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	parts := strings.SplitN(strings.TrimPrefix(req.URL.Path, "/"), "/", 2)
+	if len(parts) != 2 {
+		http.NotFound(w, req)
+		return
+	}
+	repoName, rest := parts[0], parts[1]
+
+	r, err := h.rp.GetRepoByName(repoName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case rest == "index.yaml":
+		h.serveIndex(w, r)
+	case strings.HasPrefix(rest, "charts/"):
+		h.serveChart(w, r, strings.TrimPrefix(rest, "charts/"))
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func (h *repoHTTPHandler) serveIndex(w http.ResponseWriter, r IChartRepo) {
+	index, err := r.GetIndex()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	b, err := yaml.Marshal(index)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-yaml")
+	w.Write(b)
+}
+
+func (h *repoHTTPHandler) serveChart(w http.ResponseWriter, r IChartRepo, filename string) {
+	c, err := r.GetChart(filename)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	b, err := chart.Dump(c)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	w.Write(b)
+}
@@ -25,8 +25,11 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"strings"
 	"sync"
+
+	"k8s.io/helm/pkg/provenance"
 )
 
 // IRepoProvider is a factory for IChartRepo instances.
@@ -34,6 +37,77 @@ type IRepoProvider interface {
 	GetRepoByURL(URL string) (IChartRepo, error)
 	GetRepoByName(repoName string) (IChartRepo, error)
 	GetChartByReference(reference string) (*chart.Chart, IChartRepo, error)
+	// GetChartVerified resolves reference like GetChartByReference, then
+	// verifies the chart against its provenance file, returning an error if
+	// the backing repository does not support verification.
+	GetChartVerified(reference string) (*chart.Chart, *provenance.Verification, error)
+	// Search returns the chart versions matching query across every
+	// repository known to this provider.
+	Search(query SearchQuery) ([]SearchResult, error)
+	// ResolveVersion resolves name against a SemVer constraint (e.g. "^1.2")
+	// across every repository known to this provider, returning the highest
+	// matching chart version.
+	ResolveVersion(name, constraint string) (*ChartVersion, error)
+}
+
+// TypedRepoProvider builds an IStorageRepo for repositories of a specific
+// type (GCS, S3, HTTP, OCI, ...).
+type TypedRepoProvider interface {
+	GetRepo(r IRepo) (IStorageRepo, error)
+}
+
+// typedRepoProviderFunc adapts a GetRepo-shaped function into a
+// TypedRepoProvider, for backends whose constructor returns a narrower
+// interface than TypedRepoProvider.
+type typedRepoProviderFunc func(r IRepo) (IStorageRepo, error)
+
+func (f typedRepoProviderFunc) GetRepo(r IRepo) (IStorageRepo, error) { return f(r) }
+
+// repoProviderFactory creates a TypedRepoProvider bound to the credential
+// provider it should use to resolve a repository's credentials.
+type repoProviderFactory func(cp ICredentialProvider) TypedRepoProvider
+
+var (
+	repoProviderTypesMu sync.RWMutex
+	repoProviderTypes   = map[string]repoProviderFactory{}
+)
+
+// RegisterRepoProviderType registers a factory for repositories of the given
+// type (see IRepo.GetType). It is typically called from an init function by
+// packages that implement a new repository backend.
+//
+// Registering a factory under a type that is already registered replaces the
+// previous registration.
+func RegisterRepoProviderType(repoType string, factory func(ICredentialProvider) TypedRepoProvider) {
+	repoProviderTypesMu.Lock()
+	defer repoProviderTypesMu.Unlock()
+	repoProviderTypes[repoType] = factory
+}
+
+func getRepoProviderType(repoType string) (repoProviderFactory, bool) {
+	repoProviderTypesMu.RLock()
+	defer repoProviderTypesMu.RUnlock()
+	factory, ok := repoProviderTypes[repoType]
+	return factory, ok
+}
+
+func init() {
+	RegisterRepoProviderType(GCSRepoType, func(cp ICredentialProvider) TypedRepoProvider {
+		// NewGCSRepoProvider returns the GCSRepoProvider interface, which only
+		// declares GetGCSRepo, so it cannot be used directly as a
+		// TypedRepoProvider. Adapt it with a closure that calls GetGCSRepo.
+		gcsrp := NewGCSRepoProvider(cp)
+		return typedRepoProviderFunc(gcsrp.GetGCSRepo)
+	})
+	RegisterRepoProviderType(S3RepoType, func(cp ICredentialProvider) TypedRepoProvider {
+		return NewS3RepoProvider(cp)
+	})
+	RegisterRepoProviderType(HTTPRepoType, func(cp ICredentialProvider) TypedRepoProvider {
+		return NewHTTPRepoProvider(cp)
+	})
+	RegisterRepoProviderType(OCIRepoType, func(cp ICredentialProvider) TypedRepoProvider {
+		return NewOCIRepoProvider(cp)
+	})
 }
 
 type repoProvider struct {
@@ -101,17 +175,17 @@ func (rp *repoProvider) GetRepoByName(repoName string) (IChartRepo, error) {
 }
 
 func (rp *repoProvider) createRepoByType(r IRepo) (IChartRepo, error) {
-	switch r.GetType() {
-	case GCSRepoType:
-		cr, err := rp.gcsrp.GetGCSRepo(r)
-		if err != nil {
-			return nil, err
-		}
+	factory, ok := getRepoProviderType(r.GetType())
+	if !ok {
+		return nil, fmt.Errorf("unknown repository type: %s", r.GetType())
+	}
 
-		return rp.createRepo(cr)
+	cr, err := factory(rp.cp).GetRepo(r)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil, fmt.Errorf("unknown repository type: %s", r.GetType())
+	return rp.createRepo(cr)
 }
 
 func (rp *repoProvider) createRepo(cr IChartRepo) (IChartRepo, error) {
@@ -156,31 +230,132 @@ func (rp *repoProvider) findRepoByURL(URL string) IChartRepo {
 }
 
 // GetChartByReference maps the supplied chart reference into a fully qualified
-// URL, uses the URL to find the repository it references, queries the repository
-// for the chart by URL, and returns the chart and the repository that backs it.
+// URL, uses the URL's scheme to find the repository it references, queries the
+// repository for the chart by URL, and returns the chart and the repository
+// that backs it.
+//
+// References that do not carry a scheme of their own (the historical GCS
+// shorthand form) are resolved via ParseGCSChartReference, preserving
+// backwards compatibility. A reference of the form "repoName/chartName@constraint"
+// (e.g. "myrepo/mychart@^1.2") is resolved against repoName's index using a
+// SemVer range instead of an exact version.
 func (rp *repoProvider) GetChartByReference(reference string) (*chart.Chart, IChartRepo, error) {
-	l, err := ParseGCSChartReference(reference)
+	filename, r, err := rp.resolveChartFilename(reference)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	URL, err := l.Long(true)
+	c, err := r.GetChart(filename)
 	if err != nil {
-		return nil, nil, fmt.Errorf("invalid reference %s: %s", reference, err)
+		return nil, nil, err
 	}
 
-	r, err := rp.GetRepoByURL(URL)
+	return c, r, nil
+}
+
+// GetChartVerified resolves reference exactly as GetChartByReference does,
+// then verifies the resulting chart against its provenance file. It returns
+// an error if the repository backing reference does not support
+// verification.
+func (rp *repoProvider) GetChartVerified(reference string) (*chart.Chart, *provenance.Verification, error) {
+	filename, r, err := rp.resolveChartFilename(reference)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	name := fmt.Sprintf("%s-%s.tgz", l.Name, l.Version)
-	c, err := r.GetChart(name)
+	verifier, ok := r.(ChartVerifier)
+	if !ok {
+		return nil, nil, fmt.Errorf("repository %s does not support chart verification", r.GetName())
+	}
+
+	return verifier.GetChartVerified(filename)
+}
+
+// resolveChartFilename resolves reference to the chart's storage filename
+// (e.g. "mychart-1.2.3.tgz") and the repository that backs it, handling both
+// the exact-version and "repoName/chartName@constraint" reference forms.
+func (rp *repoProvider) resolveChartFilename(reference string) (filename string, r IChartRepo, err error) {
+	if repoName, name, constraint, ok := splitVersionConstraintReference(reference); ok {
+		r, err = rp.GetRepoByName(repoName)
+		if err != nil {
+			return "", nil, err
+		}
+
+		index, err := r.GetIndex()
+		if err != nil {
+			return "", nil, err
+		}
+
+		cv, _, err := resolveVersionInIndex(index, name, constraint)
+		if err != nil {
+			return "", nil, err
+		}
+
+		return fmt.Sprintf("%s-%s.tgz", name, cv.Version), r, nil
+	}
+
+	name, version, URL, err := rp.resolveChartReference(reference)
 	if err != nil {
-		return nil, nil, err
+		return "", nil, err
 	}
 
-	return c, r, nil
+	r, err = rp.GetRepoByURL(URL)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return fmt.Sprintf("%s-%s.tgz", name, version), r, nil
+}
+
+// splitVersionConstraintReference recognizes the "repoName/chartName@constraint"
+// reference form, returning ok=false for any reference that doesn't use it.
+func splitVersionConstraintReference(reference string) (repoName, name, constraint string, ok bool) {
+	at := strings.LastIndex(reference, "@")
+	if at < 0 {
+		return "", "", "", false
+	}
+
+	slash := strings.Index(reference, "/")
+	if slash < 0 || slash > at {
+		return "", "", "", false
+	}
+
+	return reference[:slash], reference[slash+1 : at], reference[at+1:], true
+}
+
+// resolveChartReference extracts the chart name, version, and repository URL
+// encoded in reference, dispatching on the reference's URL scheme so that
+// non-GCS backends (S3, HTTP, OCI) can be addressed directly, e.g.
+// "s3://mybucket/mychart-1.2.3.tgz" or "oci://myregistry/mychart:1.2.3".
+//
+// The scheme check requires an actual "://" separator rather than trusting
+// url.Parse's Scheme field alone: url.Parse happily reports a Scheme for any
+// "word:rest" string (e.g. "mychart:1.2.3" parses with Scheme "mychart"),
+// which would otherwise misroute the historical GCS shorthand form
+// ("name:version") into the URL-based parser it was never meant for.
+func (rp *repoProvider) resolveChartReference(reference string) (name, version, repoURL string, err error) {
+	if strings.Contains(reference, "://") {
+		if u, perr := url.Parse(reference); perr == nil && u.Scheme != "" && u.Scheme != "gs" {
+			switch u.Scheme {
+			case "oci":
+				return parseOCIChartReference(reference)
+			default:
+				return parseURLChartReference(reference)
+			}
+		}
+	}
+
+	l, err := ParseGCSChartReference(reference)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	URL, err := l.Long(true)
+	if err != nil {
+		return "", "", "", fmt.Errorf("invalid reference %s: %s", reference, err)
+	}
+
+	return l.Name, l.Version, URL, nil
 }
 
 // GCSRepoProvider is a factory for GCS IRepo instances.
@@ -0,0 +1,203 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/ghodss/yaml"
+
+	"github.com/kubernetes/helm/pkg/chart"
+	"k8s.io/helm/pkg/provenance"
+)
+
+// S3RepoType identifies a chart repository backed by an Amazon S3 bucket.
+const S3RepoType = "s3"
+
+// s3RepoProvider builds IStorageRepo instances backed by S3 buckets.
+type s3RepoProvider struct {
+	cp ICredentialProvider
+}
+
+// NewS3RepoProvider creates a TypedRepoProvider for S3 repositories.
+func NewS3RepoProvider(cp ICredentialProvider) TypedRepoProvider {
+	if cp == nil {
+		cp = NewInmemCredentialProvider()
+	}
+	return &s3RepoProvider{cp: cp}
+}
+
+// GetRepo returns a repository backed by the S3 bucket and prefix encoded in
+// r.GetURL(), e.g. "s3://my-bucket/charts".
+func (s3rp *s3RepoProvider) GetRepo(r IRepo) (IStorageRepo, error) {
+	sess, err := s3rp.createSession(r.GetCredentialName())
+	if err != nil {
+		return nil, err
+	}
+
+	bucket, prefix, err := parseS3URL(r.GetURL())
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3Repo{
+		name:           r.GetName(),
+		url:            r.GetURL(),
+		credentialName: r.GetCredentialName(),
+		bucket:         bucket,
+		prefix:         prefix,
+		client:         s3.New(sess),
+	}, nil
+}
+
+func (s3rp *s3RepoProvider) createSession(credentialName string) (*session.Session, error) {
+	if credentialName == "" {
+		return session.NewSession()
+	}
+
+	c, err := s3rp.cp.GetCredential(credentialName)
+	if err != nil {
+		return nil, fmt.Errorf("credential named %s not found: %s", credentialName, err)
+	}
+
+	return session.NewSession(aws.NewConfig().WithCredentials(
+		credentials.NewStaticCredentials(c.AccessKeyID, c.SecretAccessKey, c.SessionToken),
+	))
+}
+
+// parseS3URL splits an "s3://bucket/prefix" URL into its bucket and key
+// prefix.
+func parseS3URL(URL string) (bucket, prefix string, err error) {
+	trimmed := strings.TrimPrefix(URL, "s3://")
+	if trimmed == URL {
+		return "", "", fmt.Errorf("not an s3:// URL: %s", URL)
+	}
+	parts := strings.SplitN(trimmed, "/", 2)
+	if len(parts) == 2 {
+		return parts[0], parts[1], nil
+	}
+	return parts[0], "", nil
+}
+
+// s3Repo implements IStorageRepo/IChartRepo against charts stored as objects
+// in an S3 bucket.
+type s3Repo struct {
+	name, url, credentialName string
+	bucket, prefix            string
+	client                    *s3.S3
+}
+
+func (sr *s3Repo) GetName() string { return sr.name }
+
+func (sr *s3Repo) GetURL() string { return sr.url }
+
+func (sr *s3Repo) GetCredentialName() string { return sr.credentialName }
+
+func (sr *s3Repo) GetType() string { return S3RepoType }
+
+// GetChart fetches the named chart object (e.g. "mychart-1.2.3.tgz") from the
+// bucket and prefix this repository was configured with.
+func (sr *s3Repo) GetChart(name string) (*chart.Chart, error) {
+	out, err := sr.getObject(name)
+	if err != nil {
+		return nil, err
+	}
+	defer out.Body.Close()
+
+	return chart.Load(out.Body)
+}
+
+// GetIndex fetches and parses the index.yaml object stored alongside this
+// repository's charts.
+func (sr *s3Repo) GetIndex() (*IndexFile, error) {
+	out, err := sr.getObject(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	defer out.Body.Close()
+
+	b, err := ioutil.ReadAll(out.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return LoadIndex(b)
+}
+
+// putIndex writes index back to the index.yaml object this repository was
+// configured with, implementing indexPersister so label mutations made
+// through AddLabel/RemoveLabel persist for subsequent requests.
+func (sr *s3Repo) putIndex(index *IndexFile) error {
+	b, err := yaml.Marshal(index)
+	if err != nil {
+		return err
+	}
+
+	_, err = sr.client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(sr.bucket),
+		Key:    aws.String(sr.key(indexPath)),
+		Body:   bytes.NewReader(b),
+	})
+	return err
+}
+
+// AddLabel attaches a label to the given chart version and persists the
+// updated index back to the repository.
+func (sr *s3Repo) AddLabel(name, version string, l Label) error {
+	return addLabel(sr, name, version, l)
+}
+
+// RemoveLabel removes a label from the given chart version and persists the
+// updated index back to the repository.
+func (sr *s3Repo) RemoveLabel(name, version, labelName string) error {
+	return removeLabel(sr, name, version, labelName)
+}
+
+// ListLabels returns the labels attached to the given chart version.
+func (sr *s3Repo) ListLabels(name, version string) ([]Label, error) {
+	return listLabels(sr, name, version)
+}
+
+// GetChartVerified is not yet supported for S3 repositories: there is no
+// established convention here for where a chart's .prov file would live
+// relative to its object key.
+func (sr *s3Repo) GetChartVerified(name string) (*chart.Chart, *provenance.Verification, error) {
+	return nil, nil, errors.New("chart verification is not supported for s3 repositories")
+}
+
+// key resolves name against this repository's bucket key prefix.
+func (sr *s3Repo) key(name string) string {
+	if sr.prefix == "" {
+		return name
+	}
+	return strings.TrimSuffix(sr.prefix, "/") + "/" + name
+}
+
+func (sr *s3Repo) getObject(name string) (*s3.GetObjectOutput, error) {
+	return sr.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(sr.bucket),
+		Key:    aws.String(sr.key(name)),
+	})
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver"
+)
+
+// SearchQuery describes a cross-repository chart search. A field left at its
+// zero value is not used to filter results; a query with every field empty
+// matches every chart known to the provider.
+type SearchQuery struct {
+	// Name is matched as a case-insensitive substring of the chart name.
+	Name string
+	// Keyword is matched as a case-insensitive substring of any entry in
+	// chart.Metadata.Keywords.
+	Keyword string
+	// Maintainer is matched as a case-insensitive substring of any
+	// maintainer's name or email.
+	Maintainer string
+	// Label, if Name is set, matches chart versions carrying a Label with
+	// that Name; if Value is also set, the Value must match too.
+	Label Label
+}
+
+// SearchResult is a single chart version found by a search, along with the
+// name of the repository it came from.
+type SearchResult struct {
+	RepoName string
+	Chart    *ChartVersion
+}
+
+// Search indexes the entries of every repository known to rp and returns the
+// chart versions matching query.
+func (rp *repoProvider) Search(query SearchQuery) ([]SearchResult, error) {
+	repos, err := rp.rs.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []SearchResult
+	for _, r := range repos {
+		cr, err := rp.GetRepoByName(r.GetName())
+		if err != nil {
+			continue
+		}
+
+		index, err := cr.GetIndex()
+		if err != nil {
+			continue
+		}
+
+		for _, versions := range index.Entries {
+			for _, cv := range versions {
+				if query.matches(cv) {
+					results = append(results, SearchResult{RepoName: r.GetName(), Chart: cv})
+				}
+			}
+		}
+	}
+
+	return results, nil
+}
+
+func (q SearchQuery) matches(cv *ChartVersion) bool {
+	if q.Name != "" && !containsFold(cv.Name, q.Name) {
+		return false
+	}
+
+	if q.Keyword != "" {
+		found := false
+		for _, k := range cv.Keywords {
+			if containsFold(k, q.Keyword) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if q.Maintainer != "" {
+		found := false
+		for _, m := range cv.Maintainers {
+			if containsFold(m.Name, q.Maintainer) || containsFold(m.Email, q.Maintainer) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if q.Label.Name != "" {
+		found := false
+		for _, l := range cv.Labels {
+			if l.Name == q.Label.Name && (q.Label.Value == "" || l.Value == q.Label.Value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// ResolveVersion resolves name against the given SemVer constraint (e.g.
+// "^1.2", ">=1.0 <2.0", "~1.2.3") across every repository known to rp, and
+// returns the highest matching chart version.
+func (rp *repoProvider) ResolveVersion(name, constraint string) (*ChartVersion, error) {
+	repos, err := rp.rs.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var best *ChartVersion
+	var bestVer *semver.Version
+
+	for _, r := range repos {
+		cr, err := rp.GetRepoByName(r.GetName())
+		if err != nil {
+			continue
+		}
+
+		index, err := cr.GetIndex()
+		if err != nil {
+			continue
+		}
+
+		cv, v, err := resolveVersionInIndex(index, name, constraint)
+		if err != nil {
+			continue
+		}
+
+		if bestVer == nil || v.GreaterThan(bestVer) {
+			best, bestVer = cv, v
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("no chart version found for %s matching %s", name, constraint)
+	}
+	return best, nil
+}
+
+// resolveVersionInIndex finds the highest version of name in index that
+// satisfies constraint.
+func resolveVersionInIndex(index *IndexFile, name, constraint string) (*ChartVersion, *semver.Version, error) {
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid version constraint %s: %s", constraint, err)
+	}
+
+	versions, ok := index.Entries[name]
+	if !ok {
+		return nil, nil, ErrNoChartName
+	}
+
+	var best *ChartVersion
+	var bestVer *semver.Version
+	for _, cv := range versions {
+		v, err := semver.NewVersion(cv.Version)
+		if err != nil {
+			continue
+		}
+		if !c.Check(v) {
+			continue
+		}
+		if bestVer == nil || v.GreaterThan(bestVer) {
+			best, bestVer = cv, v
+		}
+	}
+
+	if best == nil {
+		return nil, nil, fmt.Errorf("no version of %s matches %s", name, constraint)
+	}
+	return best, bestVer, nil
+}
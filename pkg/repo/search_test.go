@@ -0,0 +1,96 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repo
+
+import (
+	"testing"
+
+	"k8s.io/helm/pkg/proto/hapi/chart"
+)
+
+func newVersionedIndex(name string, versions ...string) *IndexFile {
+	index := NewIndexFile()
+	for _, v := range versions {
+		index.Add(&chart.Metadata{Name: name, Version: v}, name+"-"+v+".tgz", "", "")
+	}
+	return index
+}
+
+func TestResolveVersionInIndex(t *testing.T) {
+	index := newVersionedIndex("foo", "1.0.0", "1.2.0", "1.2.5", "2.0.0", "1.3.0-rc.1")
+
+	tests := []struct {
+		constraint  string
+		wantVersion string
+		wantErr     bool
+	}{
+		{"^1.2", "1.2.5", false},
+		{">=1.0 <2.0", "1.2.5", false},
+		{"~1.2.0", "1.2.5", false},
+		{"2.x", "2.0.0", false},
+		{">=3.0", "", true},
+		{"not-a-constraint(", "", true},
+	}
+
+	for _, tt := range tests {
+		cv, v, err := resolveVersionInIndex(index, "foo", tt.constraint)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("resolveVersionInIndex(%q): expected an error, got version %s", tt.constraint, v)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("resolveVersionInIndex(%q): unexpected error: %s", tt.constraint, err)
+			continue
+		}
+		if cv.Version != tt.wantVersion {
+			t.Errorf("resolveVersionInIndex(%q) = %s, want %s", tt.constraint, cv.Version, tt.wantVersion)
+		}
+	}
+}
+
+func TestResolveVersionInIndexUnknownChart(t *testing.T) {
+	index := newVersionedIndex("foo", "1.0.0")
+	if _, _, err := resolveVersionInIndex(index, "bar", "^1.0"); err != ErrNoChartName {
+		t.Errorf("expected ErrNoChartName for an unknown chart, got %v", err)
+	}
+}
+
+func TestSearchQueryMatchesLabel(t *testing.T) {
+	index := newVersionedIndex("foo", "1.0.0")
+	cv, err := index.Get("foo", "1.0.0")
+	if err != nil {
+		t.Fatalf("Get: %s", err)
+	}
+	cv.Labels = []Label{{Name: "status", Value: "stable"}}
+
+	q := SearchQuery{Label: Label{Name: "status", Value: "stable"}}
+	if !q.matches(cv) {
+		t.Error("expected query to match on label name and value")
+	}
+
+	q = SearchQuery{Label: Label{Name: "status", Value: "deprecated"}}
+	if q.matches(cv) {
+		t.Error("expected query not to match a label with a different value")
+	}
+
+	q = SearchQuery{Label: Label{Name: "status"}}
+	if !q.matches(cv) {
+		t.Error("expected a value-less label query to match on name alone")
+	}
+}
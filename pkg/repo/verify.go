@@ -0,0 +1,62 @@
+/*
+Copyright 2016 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/openpgp"
+
+	"github.com/kubernetes/helm/pkg/chart"
+	"k8s.io/helm/pkg/provenance"
+)
+
+// ChartVerifier is implemented by IChartRepo backends that can fetch a
+// chart's detached provenance file and verify it, surfacing the result
+// alongside the chart itself. Not every backend supports this (OCI and S3
+// repositories currently don't, since neither has an established provenance
+// publishing convention) so callers should type-assert an IChartRepo to
+// ChartVerifier rather than assuming every repo implements it.
+type ChartVerifier interface {
+	GetChartVerified(name string) (*chart.Chart, *provenance.Verification, error)
+}
+
+// verifyChartBytes verifies chartData against its provenance file provData
+// using keyring, following the same archive+.prov layout the provenance
+// package already uses for locally-packaged charts.
+//
+// Verification requires both files on disk, so chartData and provData are
+// written to a scratch directory that is removed before returning.
+func verifyChartBytes(filename string, chartData, provData []byte, keyring openpgp.KeyRing) (*provenance.Verification, error) {
+	dir, err := ioutil.TempDir("", "helm-repo-verify-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	chartPath := filepath.Join(dir, filename)
+	if err := ioutil.WriteFile(chartPath, chartData, 0644); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(chartPath+".prov", provData, 0644); err != nil {
+		return nil, err
+	}
+
+	return provenance.NewFromKeyring(keyring, "").Verify(chartPath, chartPath+".prov")
+}